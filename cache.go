@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// cacheEnvVar overrides the shared module cache location, mirroring how
+// GOMODCACHE overrides Go's module cache in cmd/go/internal/modfetch.
+const cacheEnvVar = "SIMPLE_DEPS_CACHE"
+
+// Cache is an opt-in, shared clone cache keyed by (url, resolved-sha), so
+// sibling projects on the same machine that depend on the same library at
+// the same commit share a single clone instead of each paying for their own.
+// A populated entry is chmod'd read-only (see makeEntryReadOnly) so a
+// sub-build that writes generated files into the linked working copy fails
+// loudly instead of silently corrupting the entry for every other sibling.
+type Cache struct {
+	Dir string
+}
+
+// DefaultCacheDir resolves the shared cache location: cacheEnvVar if set,
+// otherwise $XDG_CACHE_HOME/simple-deps, falling back to ~/.cache/simple-deps.
+func DefaultCacheDir() string {
+	if dir := os.Getenv(cacheEnvVar); dir != "" {
+		return dir
+	}
+
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".cache")
+	}
+
+	return filepath.Join(base, "simple-deps")
+}
+
+func NewCache(dir string) *Cache {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	return &Cache{Dir: dir}
+}
+
+// EntryPath returns <cache>/<host>/<path>@<sha>, the on-disk location of a
+// single cached clone.
+func (c *Cache) EntryPath(urlOrPath, sha string) string {
+	host, p := hostAndPathFor(urlOrPath)
+	if host == "" {
+		return filepath.Join(c.Dir, p+"@"+sha)
+	}
+	return filepath.Join(c.Dir, host, p+"@"+sha)
+}
+
+// resolveRemoteSHA asks the remote for the commit a version (tag, branch, or
+// empty/HEAD) currently points to, without needing a local clone first.
+func resolveRemoteSHA(urlOrPath, version string) (string, error) {
+	ref := version
+	if ref == "" || ref == "*" {
+		ref = "HEAD"
+	}
+
+	out, err := exec.Command("git", "ls-remote", urlOrPath, ref).Output()
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(out))
+	if line == "" {
+		// Not a ref the remote knows about; most likely version is already a
+		// commit SHA, so use it as-is and let the checkout fail loudly if not.
+		return ref, nil
+	}
+
+	fields := strings.Fields(line)
+	return fields[0], nil
+}
+
+// makeEntryReadOnly strips write permission from every file and directory
+// under entryPath once it's been populated, so that reusing it from a
+// second project can't mutate what a third project is relying on.
+func makeEntryReadOnly(entryPath string) error {
+	return filepath.Walk(entryPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.Chmod(p, 0555)
+		}
+		return os.Chmod(p, 0444)
+	})
+}
+
+// linkIntoWorkingCopy makes the cached clone at entryPath visible at
+// workingCopyPath, the layout downstream generators expect.
+func linkIntoWorkingCopy(entryPath, workingCopyPath string) error {
+	if existing, err := os.Lstat(workingCopyPath); err == nil {
+		if existing.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Readlink(workingCopyPath); err == nil && target == entryPath {
+				return nil
+			}
+		}
+		if err := os.RemoveAll(workingCopyPath); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(workingCopyPath), 0755); err != nil {
+		return err
+	}
+
+	return os.Symlink(entryPath, workingCopyPath)
+}
@@ -3,14 +3,12 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
-	"text/template"
 )
 
 type Library struct {
@@ -24,7 +22,8 @@ type Library struct {
 }
 
 type Dependencies struct {
-	Libraries []*Library
+	Libraries  []*Library
+	Generators []string
 }
 
 func NewEmptyDependencies() *Dependencies {
@@ -47,6 +46,10 @@ func (d *Dependencies) Write(path string) error {
 
 	defer f.Close()
 
+	if len(d.Generators) > 0 {
+		f.WriteString(fmt.Sprintf("generators: %s\n", strings.Join(d.Generators, ",")))
+	}
+
 	for _, lib := range d.Libraries {
 		version := lib.Version
 		if version == "" {
@@ -70,11 +73,22 @@ func (d *Dependencies) Read(fn string) error {
 
 	defer file.Close()
 
-	versionsByPath := make(map[string]string)
-
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		if rest := strings.TrimPrefix(line, "generators:"); rest != line {
+			for _, name := range strings.Split(rest, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					d.Generators = append(d.Generators, name)
+				}
+			}
+			continue
+		}
+
 		fields := strings.Split(line, " ")
 		urlOrPath := fields[0]
 		version := ""
@@ -106,11 +120,6 @@ func (d *Dependencies) Read(fn string) error {
 			RelativePath:  relativePath,
 			URL:           url,
 		})
-
-		if versionsByPath[urlOrPath] != "" && versionsByPath[urlOrPath] != version {
-			log.Fatalf("Version mismatch: %s! Versions for repositories are required to be the same.", urlOrPath)
-		}
-		versionsByPath[urlOrPath] = version
 	}
 
 	return scanner.Err()
@@ -164,117 +173,137 @@ func touchLocalOverrideDummy(path string) error {
 	return os.MkdirAll(path, 0755)
 }
 
-func (d *Dependencies) Refresh(directory string, repos *Repositories, useHead, allowLocal bool) error {
-	templateDatas := make([]*DependencyInfo, 0)
-	project := "./"
+// resolveLibraryPath finds the working copy for a single library, preferring
+// a workspace override, then the single hard-coded sibling override, then
+// falling back to an on-disk directory, and cloning it via repos otherwise.
+func resolveLibraryPath(lib *Library, repos *Repositories, directory string, useHead, allowLocal bool, lock *Lockfile, update bool, cache *Cache, workspace *Workspace) (string, error) {
+	dependencyPath := ""
 
-	for _, lib := range d.Libraries {
-		dependencyPath := ""
+	if allowLocal {
+		overridePath := ""
 
-		if allowLocal {
-			overridePath, err := checkForLocalOverride(lib)
+		if workspace != nil {
+			found, err := workspace.FindOverride(lib)
 			if err != nil {
-				return err
-			} else {
-				if overridePath != "" {
-					dependencyPath = overridePath
-					if lib.URL != nil {
-						dummyPath, _, _ := repos.GetWorkingCopyPathAndName(lib, directory)
-						err := touchLocalOverrideDummy(dummyPath)
-						if err != nil {
-							return err
-						}
-					}
-				}
+				return "", err
+			}
+			overridePath = found
+		}
+
+		if overridePath == "" {
+			found, err := checkForLocalOverride(lib)
+			if err != nil {
+				return "", err
 			}
+			overridePath = found
 		}
 
-		if dependencyPath == "" {
+		if overridePath != "" {
+			dependencyPath = overridePath
 			if lib.URL != nil {
-				clonePath, err := repos.CloneDependency(lib, directory, useHead)
+				dummyPath, _, _ := repos.GetWorkingCopyPathAndName(lib, directory)
+				err := touchLocalOverrideDummy(dummyPath)
 				if err != nil {
-					return err
-				}
-				dependencyPath = clonePath
-			} else {
-				if s, err := os.Stat(lib.UrlOrPath); err == nil && s.IsDir() {
-					version, err := repos.GetRepositoryHash(lib.UrlOrPath)
-					if err == nil {
-						log.Printf("Using directory %v (%v)", lib.UrlOrPath, version)
-					} else {
-						log.Printf("Using directory %v", lib.UrlOrPath)
-					}
+					return "", err
 				}
 			}
 		}
+	}
 
-		if dependencyPath == "" {
-			return fmt.Errorf("Unable to find dependency: %v", lib)
-		}
-
-		dependencyPath, err := filepath.Abs(dependencyPath)
-		if err != nil {
-			return err
+	if dependencyPath == "" {
+		if lib.URL != nil {
+			clonePath, err := repos.CloneDependency(lib, directory, useHead, lock, update, cache)
+			if err != nil {
+				return "", err
+			}
+			dependencyPath = clonePath
+		} else {
+			if s, err := os.Stat(lib.UrlOrPath); err == nil && s.IsDir() {
+				version, err := repos.GetRepositoryHash(lib.UrlOrPath)
+				if err == nil {
+					log.Printf("Using directory %v (%v)", lib.UrlOrPath, version)
+				} else {
+					log.Printf("Using directory %v", lib.UrlOrPath)
+				}
+				dependencyPath = lib.UrlOrPath
+			}
 		}
-		log.Printf("Dependency: %s = %s", lib.UrlOrPath, dependencyPath)
-
-		templateDatas = append(templateDatas, &DependencyInfo{
-			Name:         lib.Name,
-			Path:         dependencyPath,
-			RelativePath: lib.RelativePath,
-		})
-
-		project = filepath.Dir(lib.Configuration)
 	}
 
-	data := &TemplateData{
-		Dependencies: templateDatas,
+	if dependencyPath == "" {
+		return "", fmt.Errorf("Unable to find dependency: %v", lib)
 	}
 
-	return data.Write(project)
-}
-
-type DependencyInfo struct {
-	Name         string
-	Path         string
-	RelativePath string
-}
+	dependencyPath, err := filepath.Abs(dependencyPath)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Dependency: %s = %s", lib.UrlOrPath, dependencyPath)
 
-type TemplateData struct {
-	Dependencies []*DependencyInfo
+	return dependencyPath, nil
 }
 
-func (data *TemplateData) Write(project string) error {
-	executable, err := os.Executable()
-	if err != nil {
-		panic(err)
+// Refresh resolves the full transitive closure of d's libraries: each cloned
+// library is scanned for its own `dependencies` file, and those are folded
+// into the same resolution pass until the queue of discovered libraries
+// drains. See resolver for the worker pool and conflict resolution pass.
+//
+// lock is consulted and updated as libraries are resolved: unless update is
+// set, a library already present in lock is pinned to its locked SHA and
+// verified to still match, rather than re-resolved from Version. Refresh
+// writes the (possibly updated) lock back out to dependencies.lock alongside
+// the project's generated output.
+//
+// When useCache is set, clones are resolved from (and populated into) the
+// shared module cache (see Cache) instead of cloned directly into directory.
+//
+// When allowLocal is set, a dependencies.work file (see Workspace) next to
+// project (where the dependencies file itself lives), if present, is
+// consulted before the single hard-coded sibling override.
+func (d *Dependencies) Refresh(directory string, repos *Repositories, lock *Lockfile, useHead, allowLocal, update, useCache bool) error {
+	project := "./"
+	for _, lib := range d.Libraries {
+		project = filepath.Dir(lib.Configuration)
 	}
-	dir := filepath.Dir(executable)
 
-	templateData, err := ioutil.ReadFile(filepath.Join(dir, "dependencies.cmake.template"))
-	if err != nil {
-		return err
+	var cache *Cache
+	if useCache {
+		cache = NewCache("")
 	}
 
-	template, err := template.New("dependencies.cmake").Parse(string(templateData))
-	if err != nil {
-		return err
+	workspace := NewEmptyWorkspace()
+	if allowLocal {
+		if err := workspace.Read(filepath.Join(project, "dependencies.work")); err != nil {
+			return err
+		}
 	}
 
-	dependenciesPath := filepath.Join(project, "dependencies.cmake")
-	log.Printf("Writing %s", dependenciesPath)
+	r := newResolver(repos, directory, lock, useHead, allowLocal, update, cache, workspace)
 
-	dependenciesFile, err := os.Create(dependenciesPath)
+	infos, err := r.resolve(d.Libraries)
 	if err != nil {
 		return err
 	}
 
-	defer dependenciesFile.Close()
+	data := &TemplateData{
+		Dependencies: infos,
+	}
 
-	err = template.Execute(dependenciesFile, data)
-	if err != nil {
-		return err
+	for _, generator := range generatorsByName(d.Generators) {
+		if err := generator.Write(project, data); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return lock.Write(filepath.Join(project, "dependencies.lock"))
+}
+
+type DependencyInfo struct {
+	Name         string
+	Path         string
+	RelativePath string
+}
+
+type TemplateData struct {
+	Dependencies []*DependencyInfo
 }
@@ -0,0 +1,62 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLockAge is how long a lock file can go untouched before we assume its
+// holder (e.g. a CI job) was killed rather than just being slow, and reclaim
+// it. A clone+checkout of anything this tool deals with comfortably finishes
+// well inside this window.
+const staleLockAge = 10 * time.Minute
+
+type fileLock struct {
+	path string
+}
+
+// acquireFileLock emulates the unix flock wrapper on Windows by spinning on
+// an exclusive create of path, since LockFileEx isn't worth the extra
+// syscall plumbing for a tool this size. Blocks until held.
+//
+// Unlike the unix flock (released by the kernel when the holding process
+// exits), a bare O_EXCL lock file survives its holder's death, so a CI job
+// killed mid-populate would otherwise wedge every future run touching that
+// cache entry. To recover from that, a lock file older than staleLockAge is
+// assumed abandoned and reclaimed.
+func acquireFileLock(path string) (*fileLock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &fileLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		reclaimStaleLock(path)
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// reclaimStaleLock removes path if it's older than staleLockAge, on the
+// assumption its holder crashed or was killed rather than still working.
+func reclaimStaleLock(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if time.Since(info.ModTime()) < staleLockAge {
+		return
+	}
+	os.Remove(path)
+}
+
+func (l *fileLock) Unlock() error {
+	return os.Remove(l.path)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Generator turns a resolved TemplateData into one build-tool's view of the
+// dependency set. CMakeGenerator is the original (and still default)
+// behavior; MakefileGenerator and PkgConfigGenerator let the same resolved
+// set drive a plain Makefile sub-build or pkg-config consumers without
+// forking the tool.
+type Generator interface {
+	Name() string
+	Write(project string, data *TemplateData) error
+}
+
+// generatorsByName resolves the `generators:` line from a dependencies file
+// (or a CLI-supplied list) into concrete Generators, defaulting to the
+// original CMake-only behavior when none are named.
+func generatorsByName(names []string) []Generator {
+	if len(names) == 0 {
+		names = []string{"cmake"}
+	}
+
+	generators := make([]Generator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "cmake":
+			generators = append(generators, &CMakeGenerator{})
+		case "makefile":
+			generators = append(generators, &MakefileGenerator{})
+		case "pkgconfig":
+			generators = append(generators, &PkgConfigGenerator{})
+		default:
+			log.Printf("Unknown generator: %s", name)
+		}
+	}
+
+	return generators
+}
+
+// findTemplate resolves filename against the template search list:
+// project-local `./.simple-deps/templates/` first, then the directory the
+// executable lives in, matching how the tool has always looked for
+// dependencies.cmake.template next to itself.
+func findTemplate(project, filename string) (string, error) {
+	searchPaths := []string{filepath.Join(project, ".simple-deps", "templates", filename)}
+
+	if executable, err := os.Executable(); err == nil {
+		searchPaths = append(searchPaths, filepath.Join(filepath.Dir(executable), filename))
+	}
+
+	for _, candidate := range searchPaths {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", os.ErrNotExist
+}
+
+func writeTemplate(project, templateName, outputName string, data *TemplateData) error {
+	templatePath, err := findTemplate(project, templateName)
+	if err != nil {
+		return err
+	}
+
+	templateData, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(templateName).Parse(string(templateData))
+	if err != nil {
+		return err
+	}
+
+	outputPath := filepath.Join(project, outputName)
+	log.Printf("Writing %s", outputPath)
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+
+	defer outputFile.Close()
+
+	return tmpl.Execute(outputFile, data)
+}
+
+// CMakeGenerator is the original generator: a dependencies.cmake included by
+// the project's CMakeLists.txt.
+type CMakeGenerator struct{}
+
+func (g *CMakeGenerator) Name() string { return "cmake" }
+
+func (g *CMakeGenerator) Write(project string, data *TemplateData) error {
+	return writeTemplate(project, "dependencies.cmake.template", "dependencies.cmake", data)
+}
+
+// MakefileGenerator emits a dependencies.mk suitable for a plain Makefile's
+// `include dependencies.mk`.
+type MakefileGenerator struct{}
+
+func (g *MakefileGenerator) Name() string { return "makefile" }
+
+func (g *MakefileGenerator) Write(project string, data *TemplateData) error {
+	return writeTemplate(project, "dependencies.mk.template", "dependencies.mk", data)
+}
+
+// PkgConfigGenerator emits a dependencies.pc for tooling that consumes
+// pkg-config rather than linking against a build system directly.
+type PkgConfigGenerator struct{}
+
+func (g *PkgConfigGenerator) Name() string { return "pkgconfig" }
+
+func (g *PkgConfigGenerator) Write(project string, data *TemplateData) error {
+	return writeTemplate(project, "dependencies.pc.template", "dependencies.pc", data)
+}
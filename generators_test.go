@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func generatorNames(generators []Generator) []string {
+	names := make([]string, len(generators))
+	for i, g := range generators {
+		names[i] = g.Name()
+	}
+	return names
+}
+
+func TestGeneratorsByNameDefaultsToCMake(t *testing.T) {
+	generators := generatorsByName(nil)
+
+	if got := generatorNames(generators); len(got) != 1 || got[0] != "cmake" {
+		t.Fatalf("expected [cmake] when no generators are named, got %v", got)
+	}
+}
+
+func TestGeneratorsByNameSelectsRequested(t *testing.T) {
+	generators := generatorsByName([]string{"makefile", "pkgconfig"})
+
+	got := generatorNames(generators)
+	want := []string{"makefile", "pkgconfig"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestGeneratorsByNameSkipsUnknownNames(t *testing.T) {
+	generators := generatorsByName([]string{"bogus", "cmake"})
+
+	if got := generatorNames(generators); len(got) != 1 || got[0] != "cmake" {
+		t.Fatalf("expected unknown generator to be skipped, got %v", got)
+	}
+}
+
+func TestFindTemplatePrefersProjectLocal(t *testing.T) {
+	project := t.TempDir()
+	templatesDir := filepath.Join(project, ".simple-deps", "templates")
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	templatePath := filepath.Join(templatesDir, "dependencies.cmake.template")
+	if err := ioutil.WriteFile(templatePath, []byte("# template"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := findTemplate(project, "dependencies.cmake.template")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != templatePath {
+		t.Errorf("expected %q, got %q", templatePath, got)
+	}
+}
+
+func TestFindTemplateNotFound(t *testing.T) {
+	project := t.TempDir()
+
+	if _, err := findTemplate(project, "does-not-exist.template"); err == nil {
+		t.Fatal("expected an error when no template is found on the search path")
+	}
+}
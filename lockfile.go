@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LockedLibrary records exactly what Refresh resolved a dependency to: the
+// git commit it checked out, plus a content hash of the resulting working
+// tree so a later run can tell whether the tree still matches what was
+// locked, not just whether the SHA does.
+type LockedLibrary struct {
+	UrlOrPath    string
+	RelativePath string
+	SHA          string
+	ContentHash  string
+}
+
+// Lockfile is the in-memory form of a `dependencies.lock` file: one entry
+// per resolved library, written by Refresh and consumed on the next run so
+// that a moved tag can't silently change what gets built.
+type Lockfile struct {
+	Libraries map[string]*LockedLibrary
+
+	mu sync.Mutex
+}
+
+func NewEmptyLockfile() *Lockfile {
+	return &Lockfile{
+		Libraries: make(map[string]*LockedLibrary),
+	}
+}
+
+func lockKey(lib *Library) string {
+	if lib.RelativePath != "/" {
+		return lib.UrlOrPath + " " + lib.RelativePath
+	}
+	return lib.UrlOrPath
+}
+
+func (l *Lockfile) Read(fn string) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, " ")
+
+		urlOrPath := fields[0]
+		relativePath := "/"
+		sha := ""
+		contentHash := ""
+
+		switch len(fields) {
+		case 3:
+			sha = fields[1]
+			contentHash = fields[2]
+		case 4:
+			relativePath = fields[1]
+			sha = fields[2]
+			contentHash = fields[3]
+		default:
+			return fmt.Errorf("malformed dependencies.lock line: %q", line)
+		}
+
+		locked := &LockedLibrary{
+			UrlOrPath:    urlOrPath,
+			RelativePath: relativePath,
+			SHA:          sha,
+			ContentHash:  contentHash,
+		}
+		l.Libraries[lockKey(&Library{UrlOrPath: urlOrPath, RelativePath: relativePath})] = locked
+	}
+
+	return scanner.Err()
+}
+
+func (l *Lockfile) Write(fn string) error {
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	keys := make([]string, 0, len(l.Libraries))
+	for key := range l.Libraries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		locked := l.Libraries[key]
+		if locked.RelativePath != "/" {
+			fmt.Fprintf(f, "%s %s %s %s\n", locked.UrlOrPath, locked.RelativePath, locked.SHA, locked.ContentHash)
+		} else {
+			fmt.Fprintf(f, "%s %s %s\n", locked.UrlOrPath, locked.SHA, locked.ContentHash)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the locked entry for lib, or nil when lib isn't locked yet.
+func (l *Lockfile) Get(lib *Library) *LockedLibrary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.Libraries[lockKey(lib)]
+}
+
+// Set records (or replaces) the locked entry for lib. Safe to call from the
+// resolver's worker pool.
+func (l *Lockfile) Set(lib *Library, sha, contentHash string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Libraries[lockKey(lib)] = &LockedLibrary{
+		UrlOrPath:    lib.UrlOrPath,
+		RelativePath: lib.RelativePath,
+		SHA:          sha,
+		ContentHash:  contentHash,
+	}
+}
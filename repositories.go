@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Repositories struct {
+}
+
+func NewRepositories() *Repositories {
+	return &Repositories{}
+}
+
+func (r *Repositories) GetWorkingCopyPathAndName(lib *Library, directory string) (string, string, error) {
+	name := lib.Name
+	workingCopyPath := filepath.Join(directory, name)
+	return workingCopyPath, name, nil
+}
+
+// CloneDependency fetches (or reuses) lib's working copy. When lib is
+// already present in lock and update is false, it pins the working copy to
+// the locked SHA instead of re-resolving Version, then verifies the result
+// still matches what was locked, failing unless update is set. When cache is
+// non-nil, the clone is resolved from (and populated into) the shared cache
+// instead of cloning directly into directory.
+func (r *Repositories) CloneDependency(lib *Library, directory string, useHead bool, lock *Lockfile, update bool, cache *Cache) (string, error) {
+	workingCopyPath, _, err := r.GetWorkingCopyPathAndName(lib, directory)
+	if err != nil {
+		return "", err
+	}
+
+	if cache != nil {
+		return r.cloneViaCache(lib, workingCopyPath, useHead, lock, update, cache)
+	}
+
+	if _, err := os.Stat(workingCopyPath); err != nil {
+		if err := r.clone(lib.UrlOrPath, workingCopyPath); err != nil {
+			return "", err
+		}
+	}
+
+	locked := lock.Get(lib)
+
+	switch {
+	case locked != nil && !update:
+		if err := r.checkout(workingCopyPath, locked.SHA); err != nil {
+			return "", err
+		}
+	case !useHead && lib.Version != "" && lib.Version != "*":
+		if err := r.checkout(workingCopyPath, lib.Version); err != nil {
+			return "", err
+		}
+	}
+
+	sha, err := r.GetRepositoryHash(workingCopyPath)
+	if err != nil {
+		return "", err
+	}
+
+	contentHash, err := r.ContentHash(workingCopyPath)
+	if err != nil {
+		return "", err
+	}
+
+	if locked != nil && !update {
+		if locked.SHA != sha || locked.ContentHash != contentHash {
+			return "", fmt.Errorf("dependencies.lock mismatch for %s: working copy no longer matches locked %s (pass --update to re-resolve)", lib.UrlOrPath, locked.SHA)
+		}
+	}
+
+	lock.Set(lib, sha, contentHash)
+
+	return workingCopyPath, nil
+}
+
+// cloneViaCache resolves lib to a commit SHA, clones (or reuses) it under
+// cache rather than directory, and links it into workingCopyPath. A
+// per-entry file lock keeps two processes sharing the cache from cloning the
+// same entry at once.
+func (r *Repositories) cloneViaCache(lib *Library, workingCopyPath string, useHead bool, lock *Lockfile, update bool, cache *Cache) (string, error) {
+	locked := lock.Get(lib)
+
+	sha := ""
+	if locked != nil && !update {
+		sha = locked.SHA
+	} else {
+		version := lib.Version
+		if useHead {
+			version = ""
+		}
+		resolved, err := resolveRemoteSHA(lib.UrlOrPath, version)
+		if err != nil {
+			return "", err
+		}
+		sha = resolved
+	}
+
+	entryPath := cache.EntryPath(lib.UrlOrPath, sha)
+
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return "", err
+	}
+
+	fl, err := acquireFileLock(entryPath + ".lock")
+	if err != nil {
+		return "", err
+	}
+	defer fl.Unlock()
+
+	if _, err := os.Stat(entryPath); err != nil {
+		if err := r.clone(lib.UrlOrPath, entryPath); err != nil {
+			return "", err
+		}
+		if err := r.checkout(entryPath, sha); err != nil {
+			return "", err
+		}
+		if err := makeEntryReadOnly(entryPath); err != nil {
+			return "", err
+		}
+	}
+
+	if err := linkIntoWorkingCopy(entryPath, workingCopyPath); err != nil {
+		return "", err
+	}
+
+	contentHash, err := r.ContentHash(entryPath)
+	if err != nil {
+		return "", err
+	}
+
+	if locked != nil && !update {
+		if locked.SHA != sha || locked.ContentHash != contentHash {
+			return "", fmt.Errorf("dependencies.lock mismatch for %s: cached copy no longer matches locked %s (pass --update to re-resolve)", lib.UrlOrPath, locked.SHA)
+		}
+	}
+
+	lock.Set(lib, sha, contentHash)
+
+	return workingCopyPath, nil
+}
+
+func (r *Repositories) clone(urlOrPath, workingCopyPath string) error {
+	cmd := exec.Command("git", "clone", urlOrPath, workingCopyPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Checkout re-pins an already cloned working copy to version, used by the
+// dependency resolver's conflict resolution pass once the winning version
+// across all requesters is known.
+func (r *Repositories) Checkout(workingCopyPath, version string) error {
+	return r.checkout(workingCopyPath, version)
+}
+
+func (r *Repositories) checkout(workingCopyPath, version string) error {
+	cmd := exec.Command("git", "checkout", version)
+	cmd.Dir = workingCopyPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (r *Repositories) GetRepositoryHash(workingCopyPath string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = workingCopyPath
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RepinViaCache re-resolves lib to version's commit through the shared
+// cache and re-links workingCopyPath to the (possibly new) cache entry. Used
+// by the resolver's conflict resolution pass so a winning version found only
+// after the whole transitive graph is known doesn't bypass the cache.
+func (r *Repositories) RepinViaCache(lib *Library, workingCopyPath, version string, cache *Cache) (sha, contentHash string, err error) {
+	sha, err = resolveRemoteSHA(lib.UrlOrPath, version)
+	if err != nil {
+		return "", "", err
+	}
+
+	entryPath := cache.EntryPath(lib.UrlOrPath, sha)
+
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0755); err != nil {
+		return "", "", err
+	}
+
+	fl, err := acquireFileLock(entryPath + ".lock")
+	if err != nil {
+		return "", "", err
+	}
+	defer fl.Unlock()
+
+	if _, err := os.Stat(entryPath); err != nil {
+		if err := r.clone(lib.UrlOrPath, entryPath); err != nil {
+			return "", "", err
+		}
+		if err := r.checkout(entryPath, sha); err != nil {
+			return "", "", err
+		}
+		if err := makeEntryReadOnly(entryPath); err != nil {
+			return "", "", err
+		}
+	}
+
+	if err := linkIntoWorkingCopy(entryPath, workingCopyPath); err != nil {
+		return "", "", err
+	}
+
+	contentHash, err = r.ContentHash(entryPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sha, contentHash, nil
+}
+
+// ContentHash sums the working tree at workingCopyPath the way Go's module
+// cache hashes a zip (cmd/go/internal/modfetch's `h1:` hashes): sha256 each
+// file, then sha256 the sorted "<blob-sha256>  <path>" lines. Two checkouts
+// of the same commit always hash the same; a locally modified tree doesn't.
+func (r *Repositories) ContentHash(workingCopyPath string) (string, error) {
+	type fileSum struct {
+		path string
+		sum  string
+	}
+
+	var sums []fileSum
+
+	err := filepath.Walk(workingCopyPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(workingCopyPath, p)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		blob := sha256.Sum256(data)
+		sums = append(sums, fileSum{path: filepath.ToSlash(rel), sum: hex.EncodeToString(blob[:])})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Slice(sums, func(i, j int) bool { return sums[i].path < sums[j].path })
+
+	h := sha256.New()
+	for _, fs := range sums {
+		fmt.Fprintf(h, "%s  %s\n", fs.sum, fs.path)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func hostAndPathFor(urlOrPath string) (string, string) {
+	u, err := url.ParseRequestURI(urlOrPath)
+	if err != nil || u.Host == "" {
+		return "", path.Clean(urlOrPath)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestTree(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentHashIsStableAcrossCopies(t *testing.T) {
+	repos := &Repositories{}
+
+	one, err := ioutil.TempDir("", "simple-deps-hash-one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(one)
+
+	two, err := ioutil.TempDir("", "simple-deps-hash-two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(two)
+
+	writeTestTree(t, one)
+	writeTestTree(t, two)
+
+	hashOne, err := repos.ContentHash(one)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hashTwo, err := repos.ContentHash(two)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hashOne != hashTwo {
+		t.Errorf("expected identical trees to hash the same, got %q and %q", hashOne, hashTwo)
+	}
+}
+
+func TestContentHashChangesWithContent(t *testing.T) {
+	repos := &Repositories{}
+
+	dir, err := ioutil.TempDir("", "simple-deps-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestTree(t, dir)
+
+	before, err := repos.ContentHash(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := repos.ContentHash(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if before == after {
+		t.Error("expected hash to change after modifying a file")
+	}
+}
+
+func TestContentHashIgnoresGitDir(t *testing.T) {
+	repos := &Repositories{}
+
+	dir, err := ioutil.TempDir("", "simple-deps-hash-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestTree(t, dir)
+
+	before, err := repos.ContentHash(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".git", "objects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".git", "objects", "whatever"), []byte("git internals"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := repos.ContentHash(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if before != after {
+		t.Error("expected .git contents to be excluded from the content hash")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const defaultResolverWorkers = 4
+
+type resolveOutcome struct {
+	lib     *Library
+	path    string
+	subDeps []*Library
+	err     error
+}
+
+// resolver walks the transitive closure of a project's dependencies. Each
+// library is handed to a bounded pool of workers that clone it and look for
+// a nested `dependencies` file to expand the queue with, grapnel-style: work
+// flows in on `jobs`, results (including newly discovered libraries) flow
+// back out on `outcomes`.
+//
+// dispatch, and the pending count it maintains, are only ever touched from
+// resolve's own goroutine (seeding roots, then draining outcomes), so jobs
+// and outcomes are only closed once resolve itself has observed pending
+// drop to zero — never by a separate goroutine racing new dispatches.
+type resolver struct {
+	repos      *Repositories
+	directory  string
+	lock       *Lockfile
+	useHead    bool
+	allowLocal bool
+	update     bool
+	cache      *Cache
+	workspace  *Workspace
+
+	// processFn defaults to r.process; overridable in tests so the pending/
+	// close bookkeeping in resolve can be exercised without shelling to git.
+	processFn func(*Library) resolveOutcome
+
+	jobs     chan *Library
+	outcomes chan resolveOutcome
+
+	mu       sync.Mutex
+	pending  int
+	resolved map[string]*Library
+	paths    map[string]string
+	versions map[string][]string
+}
+
+func newResolver(repos *Repositories, directory string, lock *Lockfile, useHead, allowLocal, update bool, cache *Cache, workspace *Workspace) *resolver {
+	r := &resolver{
+		repos:      repos,
+		directory:  directory,
+		lock:       lock,
+		useHead:    useHead,
+		allowLocal: allowLocal,
+		update:     update,
+		cache:      cache,
+		workspace:  workspace,
+		jobs:       make(chan *Library, 256),
+		outcomes:   make(chan resolveOutcome, 256),
+		resolved:   make(map[string]*Library),
+		paths:      make(map[string]string),
+		versions:   make(map[string][]string),
+	}
+	r.processFn = r.process
+	return r
+}
+
+// dispatch enqueues lib unless a library with the same (UrlOrPath,
+// RelativePath) pair has already been dispatched — a project can legitimately
+// depend on two different subpaths of the same repo, so that pair, not
+// UrlOrPath alone, is what lockKey (and thus this dedup) is keyed on.
+//
+// The actual send to r.jobs happens in its own goroutine rather than inline:
+// dispatch is called from resolve's single consumer goroutine (seeding roots,
+// then fanning out each outcome's subDeps), and that same goroutine is the
+// only thing draining r.outcomes. A graph with more entries than the jobs/
+// outcomes buffers would otherwise deadlock, with resolve blocked sending to
+// a full r.jobs and every worker blocked sending to a full r.outcomes with
+// nothing left to drain it. Incrementing pending before spawning the
+// goroutine keeps the accounting correct: pending can only reach zero once
+// every dispatched send has actually been received by a worker.
+func (r *resolver) dispatch(lib *Library) {
+	key := lockKey(lib)
+
+	r.mu.Lock()
+	r.versions[key] = append(r.versions[key], lib.Version)
+	if _, exists := r.resolved[key]; exists {
+		r.mu.Unlock()
+		return
+	}
+	r.resolved[key] = lib
+	r.pending++
+	r.mu.Unlock()
+
+	go func() { r.jobs <- lib }()
+}
+
+func (r *resolver) worker() {
+	for lib := range r.jobs {
+		r.outcomes <- r.processFn(lib)
+	}
+}
+
+func (r *resolver) process(lib *Library) resolveOutcome {
+	dependencyPath, err := resolveLibraryPath(lib, r.repos, r.directory, r.useHead, r.allowLocal, r.lock, r.update, r.cache, r.workspace)
+	if err != nil {
+		return resolveOutcome{lib: lib, err: err}
+	}
+
+	r.mu.Lock()
+	r.paths[lockKey(lib)] = dependencyPath
+	r.mu.Unlock()
+
+	subDeps := NewEmptyDependencies()
+	nestedFile := filepath.Join(dependencyPath, "dependencies")
+	if s, err := os.Stat(nestedFile); err == nil && s.Mode().IsRegular() {
+		if err := subDeps.Read(nestedFile); err != nil {
+			return resolveOutcome{lib: lib, err: err}
+		}
+	}
+
+	return resolveOutcome{lib: lib, path: dependencyPath, subDeps: subDeps.Libraries}
+}
+
+// resolve walks the transitive closure rooted at the given libraries and
+// returns the flattened, de-duplicated dependency set, reconciling any
+// version conflicts across requesters before returning.
+func (r *resolver) resolve(roots []*Library) ([]*DependencyInfo, error) {
+	for i := 0; i < defaultResolverWorkers; i++ {
+		go r.worker()
+	}
+
+	for _, lib := range roots {
+		r.dispatch(lib)
+	}
+
+	for {
+		r.mu.Lock()
+		done := r.pending == 0
+		r.mu.Unlock()
+		if done {
+			break
+		}
+
+		outcome := <-r.outcomes
+
+		r.mu.Lock()
+		r.pending--
+		r.mu.Unlock()
+
+		if outcome.err != nil {
+			return nil, outcome.err
+		}
+		for _, sub := range outcome.subDeps {
+			r.dispatch(sub)
+		}
+	}
+
+	close(r.jobs)
+	close(r.outcomes)
+
+	if err := r.reconcileVersions(); err != nil {
+		return nil, err
+	}
+
+	infos := make([]*DependencyInfo, 0, len(r.resolved))
+	for key, lib := range r.resolved {
+		infos = append(infos, &DependencyInfo{
+			Name:         lib.Name,
+			Path:         r.paths[key],
+			RelativePath: lib.RelativePath,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+// reconcileVersions replaces the old per-file version-mismatch check with a
+// proper conflict resolution pass: once every requester across the whole
+// transitive graph has been discovered, pick the highest semver-compatible
+// version for each dependency and re-checkout if that differs from what was
+// cloned while the queue was still draining.
+func (r *resolver) reconcileVersions() error {
+	for key, requestedVersions := range r.versions {
+		chosen, err := highestCompatibleVersion(requestedVersions)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+
+		lib := r.resolved[key]
+		if chosen == "" || chosen == lib.Version || lib.URL == nil || r.useHead {
+			continue
+		}
+
+		workingCopyPath := r.paths[key]
+
+		// A library resolved to a local override was never cloned into
+		// directory, so the developer's checkout is left as it is.
+		clonePath, _, _ := r.repos.GetWorkingCopyPathAndName(lib, r.directory)
+		if abs, err := filepath.Abs(clonePath); err != nil || abs != workingCopyPath {
+			continue
+		}
+
+		var sha, contentHash string
+		if r.cache != nil {
+			sha, contentHash, err = r.repos.RepinViaCache(lib, workingCopyPath, chosen, r.cache)
+		} else {
+			if err = r.repos.Checkout(workingCopyPath, chosen); err == nil {
+				sha, err = r.repos.GetRepositoryHash(workingCopyPath)
+			}
+			if err == nil {
+				contentHash, err = r.repos.ContentHash(workingCopyPath)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		lib.Version = chosen
+		r.lock.Set(lib, sha, contentHash)
+	}
+
+	return nil
+}
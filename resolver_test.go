@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolverDedupesByUrlAndRelativePath(t *testing.T) {
+	r := newResolver(nil, "", NewEmptyLockfile(), false, false, false, nil, nil)
+
+	a := &Library{UrlOrPath: "https://example.com/foo.git", RelativePath: "/", Name: "foo"}
+	b := &Library{UrlOrPath: "https://example.com/foo.git", RelativePath: "/sub", Name: "foo_sub"}
+	aAgain := &Library{UrlOrPath: "https://example.com/foo.git", RelativePath: "/", Name: "foo"}
+
+	for _, lib := range []*Library{a, b, aAgain} {
+		r.dispatch(lib)
+	}
+
+	if len(r.resolved) != 2 {
+		t.Fatalf("expected 2 distinct (UrlOrPath, RelativePath) entries, got %d", len(r.resolved))
+	}
+	if _, ok := r.resolved[lockKey(a)]; !ok {
+		t.Errorf("missing entry for root path")
+	}
+	if _, ok := r.resolved[lockKey(b)]; !ok {
+		t.Errorf("missing entry for /sub path, a same-URL different-subpath dependency was dropped")
+	}
+}
+
+// TestResolverChainedDiscoveryDoesNotPanic exercises the exact shape the
+// reviewer reported: A's processing discovers B, B's discovers C. Each
+// outcome is still buffered in r.outcomes when the newly discovered
+// dependency gets dispatched, which used to race a WaitGroup-driven closer
+// goroutine into closing r.jobs while dispatch was still sending to it.
+func TestResolverChainedDiscoveryDoesNotPanic(t *testing.T) {
+	r := newResolver(nil, "", NewEmptyLockfile(), false, false, false, nil, nil)
+
+	b := &Library{UrlOrPath: "b", RelativePath: "/", Name: "b"}
+	c := &Library{UrlOrPath: "c", RelativePath: "/", Name: "c"}
+
+	var mu sync.Mutex
+	processed := map[string]bool{}
+
+	r.processFn = func(lib *Library) resolveOutcome {
+		mu.Lock()
+		processed[lib.UrlOrPath] = true
+		mu.Unlock()
+
+		switch lib.UrlOrPath {
+		case "a":
+			return resolveOutcome{lib: lib, path: "/a", subDeps: []*Library{b}}
+		case "b":
+			return resolveOutcome{lib: lib, path: "/b", subDeps: []*Library{c}}
+		default:
+			return resolveOutcome{lib: lib, path: "/" + lib.UrlOrPath}
+		}
+	}
+
+	a := &Library{UrlOrPath: "a", RelativePath: "/", Name: "a"}
+
+	infos, err := r.resolve([]*Library{a})
+	if err != nil {
+		t.Fatalf("resolve returned an error: %v", err)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("expected a, b, c all resolved, got %d: %+v", len(infos), infos)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		mu.Lock()
+		ok := processed[name]
+		mu.Unlock()
+		if !ok {
+			t.Errorf("%s was never processed", name)
+		}
+	}
+}
+
+// TestResolverLargeFanoutDoesNotDeadlock reproduces the reviewer's repro: a
+// single root discovering far more subDeps than the jobs/outcomes buffers
+// hold. Run on a goroutine with a timeout so a regression hangs this test
+// instead of the whole suite.
+func TestResolverLargeFanoutDoesNotDeadlock(t *testing.T) {
+	const fanout = 2000
+
+	r := newResolver(nil, "", NewEmptyLockfile(), false, false, false, nil, nil)
+
+	r.processFn = func(lib *Library) resolveOutcome {
+		if lib.UrlOrPath != "root" {
+			return resolveOutcome{lib: lib, path: "/" + lib.UrlOrPath}
+		}
+
+		subDeps := make([]*Library, 0, fanout)
+		for i := 0; i < fanout; i++ {
+			name := fmt.Sprintf("leaf-%d", i)
+			subDeps = append(subDeps, &Library{UrlOrPath: name, RelativePath: "/", Name: name})
+		}
+		return resolveOutcome{lib: lib, path: "/root", subDeps: subDeps}
+	}
+
+	root := &Library{UrlOrPath: "root", RelativePath: "/", Name: "root"}
+
+	done := make(chan []*DependencyInfo, 1)
+	errs := make(chan error, 1)
+	go func() {
+		infos, err := r.resolve([]*Library{root})
+		if err != nil {
+			errs <- err
+			return
+		}
+		done <- infos
+	}()
+
+	select {
+	case infos := <-done:
+		if len(infos) != fanout+1 {
+			t.Fatalf("expected %d resolved libraries, got %d", fanout+1, len(infos))
+		}
+	case err := <-errs:
+		t.Fatalf("resolve returned an error: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("resolve did not complete within 10s, likely deadlocked on large fanout")
+	}
+}
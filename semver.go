@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type semver struct {
+	major, minor, patch int
+	raw                 string
+}
+
+func parseSemver(version string) (semver, bool) {
+	trimmed := strings.TrimPrefix(version, "v")
+	parts := strings.SplitN(trimmed, ".", 3)
+	if len(parts) == 0 || parts[0] == "" {
+		return semver{}, false
+	}
+
+	values := [3]int{}
+	for i, part := range parts {
+		part = strings.SplitN(part, "-", 2)[0]
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		values[i] = n
+	}
+
+	return semver{major: values[0], minor: values[1], patch: values[2], raw: version}, true
+}
+
+func (s semver) compatibleWith(o semver) bool {
+	return s.major == o.major
+}
+
+func (s semver) lessThan(o semver) bool {
+	if s.major != o.major {
+		return s.major < o.major
+	}
+	if s.minor != o.minor {
+		return s.minor < o.minor
+	}
+	return s.patch < o.patch
+}
+
+// highestCompatibleVersion picks the highest semver among versions, as long as
+// they're all compatible with one another (same major). Versions that don't
+// parse as semver are only acceptable when every requester agrees verbatim.
+func highestCompatibleVersion(versions []string) (string, error) {
+	unique := make(map[string]bool)
+	for _, v := range versions {
+		if v != "" && v != "*" {
+			unique[v] = true
+		}
+	}
+
+	if len(unique) == 0 {
+		return "", nil
+	}
+
+	if len(unique) == 1 {
+		for v := range unique {
+			return v, nil
+		}
+	}
+
+	var parsed []semver
+	for v := range unique {
+		sv, ok := parseSemver(v)
+		if !ok {
+			return "", fmt.Errorf("version mismatch: %s (not comparable as semver)", strings.Join(sortedKeys(unique), ", "))
+		}
+		parsed = append(parsed, sv)
+	}
+
+	highest := parsed[0]
+	for _, sv := range parsed[1:] {
+		if !sv.compatibleWith(highest) {
+			return "", fmt.Errorf("version mismatch: %s (incompatible major versions)", strings.Join(sortedKeys(unique), ", "))
+		}
+		if highest.lessThan(sv) {
+			highest = sv
+		}
+	}
+
+	return highest.raw, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
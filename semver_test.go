@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestHighestCompatibleVersionPicksHighestMinor(t *testing.T) {
+	chosen, err := highestCompatibleVersion([]string{"v1.2.0", "v1.4.0", "v1.3.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen != "v1.4.0" {
+		t.Errorf("expected v1.4.0, got %q", chosen)
+	}
+}
+
+func TestHighestCompatibleVersionSingleRequester(t *testing.T) {
+	chosen, err := highestCompatibleVersion([]string{"v2.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen != "v2.0.0" {
+		t.Errorf("expected v2.0.0, got %q", chosen)
+	}
+}
+
+func TestHighestCompatibleVersionIgnoresWildcards(t *testing.T) {
+	chosen, err := highestCompatibleVersion([]string{"*", "", "v1.0.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen != "v1.0.0" {
+		t.Errorf("expected v1.0.0, got %q", chosen)
+	}
+}
+
+func TestHighestCompatibleVersionNoRequestedVersions(t *testing.T) {
+	chosen, err := highestCompatibleVersion([]string{"*", ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chosen != "" {
+		t.Errorf("expected no chosen version, got %q", chosen)
+	}
+}
+
+func TestHighestCompatibleVersionRejectsIncompatibleMajors(t *testing.T) {
+	if _, err := highestCompatibleVersion([]string{"v1.0.0", "v2.0.0"}); err == nil {
+		t.Fatal("expected an error for incompatible major versions")
+	}
+}
+
+func TestHighestCompatibleVersionRejectsNonSemver(t *testing.T) {
+	if _, err := highestCompatibleVersion([]string{"master", "v1.0.0"}); err == nil {
+		t.Fatal("expected an error when versions aren't all comparable as semver")
+	}
+}
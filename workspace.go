@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorkspaceReplace pins a single module to a local path, taking priority
+// over glob-matched Roots, the way a `replace` directive overrides whatever
+// a glob would otherwise have found.
+type WorkspaceReplace struct {
+	Module string
+	Path   string
+}
+
+// Workspace is the parsed form of a `dependencies.work` file: a set of
+// override root globs (e.g. `../*`) plus explicit replace directives,
+// letting a developer override every sibling checkout in a monorepo at once
+// instead of editing every project's dependency file.
+type Workspace struct {
+	Roots    []string
+	Replaces []WorkspaceReplace
+}
+
+func NewEmptyWorkspace() *Workspace {
+	return &Workspace{}
+}
+
+func (w *Workspace) Read(fn string) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rest := strings.TrimPrefix(line, "replace "); rest != line {
+			parts := strings.SplitN(rest, "=>", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			w.Replaces = append(w.Replaces, WorkspaceReplace{
+				Module: strings.TrimSpace(parts[0]),
+				Path:   strings.TrimSpace(parts[1]),
+			})
+			continue
+		}
+
+		w.Roots = append(w.Roots, line)
+	}
+
+	return scanner.Err()
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
+
+// FindOverride returns the working-copy path a workspace wants used for lib,
+// or "" if nothing in the workspace matches it. Replace directives are
+// checked first, then each root glob in order, matching by Library.Name the
+// same way checkForLocalOverride does for the single hard-coded `../<name>`.
+func (w *Workspace) FindOverride(lib *Library) (string, error) {
+	for _, replace := range w.Replaces {
+		if replace.Module == lib.UrlOrPath || replace.Module == lib.Name {
+			return filepath.Abs(expandHome(replace.Path))
+		}
+	}
+
+	for _, pattern := range w.Roots {
+		matches, err := filepath.Glob(expandHome(pattern))
+		if err != nil {
+			return "", err
+		}
+
+		for _, match := range matches {
+			if filepath.Base(match) != lib.Name {
+				continue
+			}
+			if s, err := os.Stat(match); err != nil || s.Mode().IsRegular() {
+				continue
+			}
+			return filepath.Abs(match)
+		}
+	}
+
+	return "", nil
+}
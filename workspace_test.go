@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceReadParsesRootsAndReplaces(t *testing.T) {
+	dir := t.TempDir()
+	workFile := filepath.Join(dir, "dependencies.work")
+	content := "../*\n# a comment\n\nreplace github.com/foo/bar => ../local-bar\n~/src/work/*\n"
+	if err := ioutil.WriteFile(workFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewEmptyWorkspace()
+	if err := w.Read(workFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(w.Roots) != 2 || w.Roots[0] != "../*" || w.Roots[1] != "~/src/work/*" {
+		t.Fatalf("unexpected roots: %v", w.Roots)
+	}
+
+	if len(w.Replaces) != 1 {
+		t.Fatalf("expected 1 replace directive, got %d", len(w.Replaces))
+	}
+	if w.Replaces[0].Module != "github.com/foo/bar" || w.Replaces[0].Path != "../local-bar" {
+		t.Errorf("unexpected replace: %+v", w.Replaces[0])
+	}
+}
+
+func TestWorkspaceReadMissingFileIsNotAnError(t *testing.T) {
+	w := NewEmptyWorkspace()
+	if err := w.Read(filepath.Join(t.TempDir(), "dependencies.work")); err != nil {
+		t.Fatalf("expected a missing workspace file to be a no-op, got %v", err)
+	}
+}
+
+func TestWorkspaceFindOverrideReplaceTakesPrecedenceOverGlob(t *testing.T) {
+	dir := t.TempDir()
+
+	siblingRoot := filepath.Join(dir, "siblings")
+	if err := os.MkdirAll(filepath.Join(siblingRoot, "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	replacement := filepath.Join(dir, "explicit-foo")
+	if err := os.MkdirAll(replacement, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Workspace{
+		Roots:    []string{filepath.Join(siblingRoot, "*")},
+		Replaces: []WorkspaceReplace{{Module: "foo", Path: replacement}},
+	}
+
+	got, err := w.FindOverride(&Library{Name: "foo", UrlOrPath: "foo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want, _ := filepath.Abs(replacement)
+	if got != want {
+		t.Errorf("expected the replace directive to win over the glob match, got %q want %q", got, want)
+	}
+}
+
+func TestWorkspaceFindOverrideMatchesGlobByName(t *testing.T) {
+	dir := t.TempDir()
+
+	siblingRoot := filepath.Join(dir, "siblings")
+	if err := os.MkdirAll(filepath.Join(siblingRoot, "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(siblingRoot, "bar"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Workspace{Roots: []string{filepath.Join(siblingRoot, "*")}}
+
+	got, err := w.FindOverride(&Library{Name: "bar", UrlOrPath: "bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want, _ := filepath.Abs(filepath.Join(siblingRoot, "bar"))
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWorkspaceFindOverrideNoMatchReturnsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	w := &Workspace{Roots: []string{filepath.Join(dir, "*")}}
+
+	got, err := w.FindOverride(&Library{Name: "nope", UrlOrPath: "nope"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "" {
+		t.Errorf("expected no override, got %q", got)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	got := expandHome("~/src/work")
+	want := filepath.Join(home, "src/work")
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if got := expandHome("../no-tilde"); got != "../no-tilde" {
+		t.Errorf("expected paths without a leading ~ to be unchanged, got %q", got)
+	}
+}